@@ -19,6 +19,12 @@ import (
 
 	"github.com/vishvananda/netlink"
 	"golang.org/x/sys/unix"
+
+	"github.com/randomizedcoder/goRoutes/internal/api"
+	"github.com/randomizedcoder/goRoutes/internal/config"
+	"github.com/randomizedcoder/goRoutes/internal/events"
+	"github.com/randomizedcoder/goRoutes/internal/netns"
+	"github.com/randomizedcoder/goRoutes/internal/routes"
 )
 
 const (
@@ -35,18 +41,14 @@ const (
 	promMaxRequestsInFlight = 10
 	promEnableOpenMetrics   = true
 
+	apiListenCst = ":9902"
+
 	quantileError    = 0.05
 	summaryVecMaxAge = 5 * time.Minute
 
 	goMaxProcsCst = 1
 )
 
-// GRE support
-// https://github.com/vishvananda/netlink/pull/263/files
-
-// type Gretun struct {
-// https://github.com/vishvananda/netlink/blob/main/link.go#L1213
-
 var (
 	// Passed by "go build -ldflags" for the show version
 	commit string
@@ -84,7 +86,7 @@ func main() {
 
 	log.Println(programNameCst)
 
-	_, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	go initSignalHandler(cancel)
@@ -93,12 +95,20 @@ func main() {
 
 	bridgeName := flag.String("bridgeName", dockerBridgeNameCst, "docker bridge name")
 
+	configPath := flag.String("config", "", "path to YAML route config. When set, goRoutes runs as a reconciling daemon instead of adding the single example route")
+
+	watchEvents := flag.Bool("watchEvents", true, "subscribe to netlink route/link events and export them as Prometheus metrics")
+
+	netnsName := flag.String("netns", "", "network namespace (as found under /var/run/netns) to add the example route in; empty means the host namespace")
+
 	// https://pkg.go.dev/net#Listen
 	promListen := flag.String("promListen", promListenCst, "Prometheus http listening socket")
 	promPath := flag.String("promPath", promPathCst, "Prometheus http path. Default = /metrics")
 	// curl -s http://[::1]:9111/metrics 2>&1 | grep -v "#"
 	// curl -s http://127.0.0.1:9111/metrics 2>&1 | grep -v "#"
 
+	apiListen := flag.String("apiListen", apiListenCst, "control API http listening socket")
+
 	dl := flag.Int("dl", debugLevelCst, "nasty debugLevel")
 
 	max := flag.Int("max", goMaxProcsCst, "GOMAXPROCS")
@@ -116,13 +126,34 @@ func main() {
 
 	go initPromHandler(*promPath, *promListen)
 
+	go initAPIHandler(*apiListen)
+
+	if *watchEvents {
+		go events.New(debugLevel).Run(ctx)
+	}
+
 	if debugLevel > 10 {
 		log.Println("service init complete")
 	}
 
-	ethLink, errL := netlink.LinkByName(*bridgeName)
+	if *configPath != "" {
+		runReconciler(ctx, *configPath)
+		return
+	}
+
+	var nsHandle *netns.Handle
+	if *netnsName != "" {
+		var errN error
+		nsHandle, errN = netns.ByName(*netnsName)
+		if errN != nil {
+			log.Fatal("netns.ByName(*netnsName) errN:", errN)
+		}
+		defer nsHandle.Close()
+	}
+
+	ethLink, errL := linkByName(nsHandle, *bridgeName)
 	if errL != nil {
-		log.Fatal("netlink.LinkByName(*bridgeName) errL:", errL)
+		log.Fatal("linkByName(nsHandle, *bridgeName) errL:", errL)
 	}
 
 	// https://pkg.go.dev/github.com/vishvananda/netlink
@@ -152,9 +183,9 @@ func main() {
 	}
 	log.Printf("route:%v", route)
 
-	errR := netlink.RouteAdd(&route)
+	errR := routeAdd(nsHandle, &route)
 	if errR != nil {
-		log.Fatal("netlink.RouteAdd(&route) errR:", errR)
+		log.Fatal("routeAdd(nsHandle, &route) errR:", errR)
 	}
 
 	log.Println(programNameCst + ": That's all Folks!")
@@ -172,6 +203,19 @@ func initSignalHandler(cancel context.CancelFunc) {
 	os.Exit(0)
 }
 
+// runReconciler loads the declarative route config from configPath and
+// runs the reconciliation loop until ctx is cancelled.
+func runReconciler(ctx context.Context, configPath string) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		log.Fatal("config.Load(configPath) err:", err)
+	}
+
+	log.Printf("runReconciler loaded %d routes from %s", len(cfg.Routes), configPath)
+
+	routes.New(debugLevel, cfg).Run(ctx)
+}
+
 // initPromHandler starts the prom handler with error checking
 func initPromHandler(promPath string, promListen string) {
 	// https: //pkg.go.dev/github.com/prometheus/client_golang/prometheus/promhttp?tab=doc#HandlerOpts
@@ -190,52 +234,29 @@ func initPromHandler(promPath string, promListen string) {
 	}()
 }
 
-// links, err := netlink.LinkList()
-// if err != nil {
-// 	panic(err)
-// }
-// for _, link := range links {
-// 	fmt.Println(link.Attrs().Name)
-// }
-
-// _, defaultNet, _ := net.ParseCIDR("0.0.0.0/0")
-// // delete default route first
-// if err := t.RouteDel(&netlink.Route{LinkIndex: link.Attrs().Index, Dst: defaultNet}); err != nil {
-// 	if errno, ok := err.(syscall.Errno); !ok || errno != syscall.ESRCH {
-// 		return fmt.Errorf("could not update default route: %s", err)
-// 	}
-// }
-
-// log.Infof("Setting default gateway to %s", endpoint.Network.Gateway.IP)
-// route := &netlink.Route{LinkIndex: link.Attrs().Index, Dst: defaultNet, Gw: endpoint.Network.Gateway.IP}
-// if err := t.RouteAdd(route); err != nil {
-// 	detail := fmt.Sprintf("failed to add gateway route for endpoint %s: %s", endpoint.Network.Name, err)
-// 	return errors.New(detail)
-// }
-
-// if err := netlink.RouteAdd(rt); err != nil {
-// 	if !os.IsExist(err) {
-// 		return fmt.Errorf("failed to add route '%s via %v dev %v': %v",
-// 			r.Destination.String(), r.NextHop, ifName, err)
-// 	}
-// }
-
-// func main() {
-//     la := netlink.NewLinkAttrs()
-//     la.Name = "foobar"
-
-//     l, err := netlink.LinkByName(la.Name)
-//     if err == nil {
-//         log.Fatalf("Link with name %s already exists: %v", la.Name, err) // HERE
-//     } else {
-//         myGretun := &netlink.Gretun{LinkAttrs: la}
-//         myGretun.Remote = net.ParseIP("2001:da8::1")
-//         myGretun.Local = net.ParseIP("2001:da8::2")
-//         err := netlink.LinkAdd(myGretun)
-//         if err != nil {
-//             log.Fatalf("Could not add %s: %v", la.Name, err)
-//         }
-//         l = myGretun
-//     }
-//     fmt.Printf("Information about the created link: %v", l)
-// }
+// linkByName looks up a link by name, in nsHandle's namespace if set,
+// or the host namespace otherwise.
+func linkByName(nsHandle *netns.Handle, name string) (netlink.Link, error) {
+	if nsHandle == nil {
+		return netlink.LinkByName(name)
+	}
+	return nsHandle.Netlink().LinkByName(name)
+}
+
+// routeAdd adds route, in nsHandle's namespace if set, or the host
+// namespace otherwise.
+func routeAdd(nsHandle *netns.Handle, route *netlink.Route) error {
+	if nsHandle == nil {
+		return netlink.RouteAdd(route)
+	}
+	return nsHandle.Netlink().RouteAdd(route)
+}
+
+// initAPIHandler starts the control API http server with error checking
+func initAPIHandler(apiListen string) {
+	srv := api.New(debugLevel)
+	err := http.ListenAndServe(apiListen, srv.Handler())
+	if err != nil {
+		log.Fatal("api error", err)
+	}
+}
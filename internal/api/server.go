@@ -0,0 +1,321 @@
+// Package api implements a control-plane HTTP API that lets external
+// callers create, list, and delete netlink routes at runtime, turning
+// goRoutes from a single hard-coded example into a reusable network
+// daemon that other services (e.g. orchestrators managing docker
+// bridges) can drive.
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	pC = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "api",
+			Name:      "goRoutes",
+			Help:      "goRoutes control API counters",
+		},
+		[]string{"function", "variable", "type"},
+	)
+)
+
+// routeTypes maps the "type" JSON field to the corresponding
+// unix.RTN_* route type constant.
+var routeTypes = map[string]int{
+	"":          unix.RTN_UNICAST,
+	"unicast":   unix.RTN_UNICAST,
+	"multicast": unix.RTN_MULTICAST,
+	"blackhole": unix.RTN_BLACKHOLE,
+	"anycast":   unix.RTN_ANYCAST,
+}
+
+var routeTypeNames = map[int]string{
+	unix.RTN_UNICAST:   "unicast",
+	unix.RTN_MULTICAST: "multicast",
+	unix.RTN_BLACKHOLE: "blackhole",
+	unix.RTN_ANYCAST:   "anycast",
+}
+
+// netlinkAPI is the subset of netlink operations the control API needs,
+// mirroring routes.netlinkAPI so the handlers can be driven by a fake
+// in tests instead of the real kernel routing table.
+type netlinkAPI interface {
+	LinkByName(name string) (netlink.Link, error)
+	LinkByIndex(index int) (netlink.Link, error)
+	RouteList(link netlink.Link, family int) ([]netlink.Route, error)
+	RouteReplace(route *netlink.Route) error
+	RouteDel(route *netlink.Route) error
+}
+
+// hostNetlink implements netlinkAPI against the host (default) network
+// namespace, via the package-level netlink functions.
+type hostNetlink struct{}
+
+func (hostNetlink) LinkByName(name string) (netlink.Link, error) { return netlink.LinkByName(name) }
+func (hostNetlink) LinkByIndex(index int) (netlink.Link, error)  { return netlink.LinkByIndex(index) }
+func (hostNetlink) RouteList(link netlink.Link, family int) ([]netlink.Route, error) {
+	return netlink.RouteList(link, family)
+}
+func (hostNetlink) RouteReplace(route *netlink.Route) error { return netlink.RouteReplace(route) }
+func (hostNetlink) RouteDel(route *netlink.Route) error     { return netlink.RouteDel(route) }
+
+// Route is the JSON representation of a netlink route exposed over the
+// control API.
+type Route struct {
+	ID    string `json:"id,omitempty"`
+	Link  string `json:"link"`
+	Dst   string `json:"dst"`
+	Gw    string `json:"gw,omitempty"`
+	Type  string `json:"type,omitempty"`
+	Table int    `json:"table"`
+}
+
+// Server holds the control API's http.Handler.
+type Server struct {
+	debugLevel int
+	netlink    netlinkAPI
+	mux        *http.ServeMux
+}
+
+// New returns a Server with its routes registered.
+func New(debugLevel int) *Server {
+	return newServer(debugLevel, hostNetlink{})
+}
+
+// newServer returns a Server backed by the given netlinkAPI, so tests
+// can drive the handlers with a fake instead of the real kernel routing
+// table.
+func newServer(debugLevel int, nl netlinkAPI) *Server {
+	s := &Server{
+		debugLevel: debugLevel,
+		netlink:    nl,
+		mux:        http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("GET /healthz", s.healthz)
+	s.mux.HandleFunc("GET /v1/routes", s.listRoutes)
+	s.mux.HandleFunc("POST /v1/routes", s.createRoute)
+	s.mux.HandleFunc("DELETE /v1/routes/{id}", s.deleteRoute)
+
+	return s
+}
+
+// Handler returns the Server's http.Handler.
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
+
+func (s *Server) listRoutes(w http.ResponseWriter, r *http.Request) {
+	rts, err := s.netlink.RouteList(nil, unix.AF_UNSPEC)
+	if err != nil {
+		log.Printf("api.listRoutes s.netlink.RouteList() err:%v", err)
+		pC.WithLabelValues("listRoutes", "RouteList", "error").Inc()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if tableStr := r.URL.Query().Get("table"); tableStr != "" {
+		table, errA := strconv.Atoi(tableStr)
+		if errA != nil {
+			http.Error(w, "invalid table", http.StatusBadRequest)
+			return
+		}
+		rts = filterByTable(rts, table)
+	}
+
+	out := make([]Route, 0, len(rts))
+	for _, rt := range rts {
+		out = append(out, toAPIRoute(s.netlink, rt))
+	}
+
+	writeJSON(w, http.StatusOK, out)
+	pC.WithLabelValues("listRoutes", "RouteList", "ok").Inc()
+}
+
+func (s *Server) createRoute(w http.ResponseWriter, r *http.Request) {
+	var in Route
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rt, err := fromAPIRoute(s.netlink, in)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.netlink.RouteReplace(rt); err != nil {
+		log.Printf("api.createRoute s.netlink.RouteReplace(%v) err:%v", rt, err)
+		pC.WithLabelValues("createRoute", "RouteReplace", "error").Inc()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	pC.WithLabelValues("createRoute", "RouteReplace", "ok").Inc()
+	writeJSON(w, http.StatusCreated, toAPIRoute(s.netlink, *rt))
+}
+
+func (s *Server) deleteRoute(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	rt, err := decodeRouteID(id)
+	if err != nil {
+		http.Error(w, "invalid route id: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.netlink.RouteDel(rt); err != nil {
+		log.Printf("api.deleteRoute s.netlink.RouteDel(%v) err:%v", rt, err)
+		pC.WithLabelValues("deleteRoute", "RouteDel", "error").Inc()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	pC.WithLabelValues("deleteRoute", "RouteDel", "ok").Inc()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func filterByTable(rts []netlink.Route, table int) []netlink.Route {
+	out := rts[:0]
+	for _, rt := range rts {
+		if rt.Table == table {
+			out = append(out, rt)
+		}
+	}
+	return out
+}
+
+func toAPIRoute(api netlinkAPI, rt netlink.Route) Route {
+	dst := ""
+	if rt.Dst != nil {
+		dst = rt.Dst.String()
+	}
+
+	gw := ""
+	if rt.Gw != nil {
+		gw = rt.Gw.String()
+	}
+
+	link := ""
+	if l, err := api.LinkByIndex(rt.LinkIndex); err == nil {
+		link = l.Attrs().Name
+	}
+
+	return Route{
+		ID:    encodeRouteID(rt),
+		Link:  link,
+		Dst:   dst,
+		Gw:    gw,
+		Type:  routeTypeNames[rt.Type],
+		Table: rt.Table,
+	}
+}
+
+func fromAPIRoute(api netlinkAPI, in Route) (*netlink.Route, error) {
+	link, err := api.LinkByName(in.Link)
+	if err != nil {
+		return nil, fmt.Errorf("api.LinkByName(%s): %w", in.Link, err)
+	}
+
+	typ, ok := routeTypes[in.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown route type %q", in.Type)
+	}
+
+	var dst *net.IPNet
+	if in.Dst != "" {
+		_, dst, err = net.ParseCIDR(in.Dst)
+		if err != nil {
+			return nil, fmt.Errorf("net.ParseCIDR(%s): %w", in.Dst, err)
+		}
+	}
+
+	var gw net.IP
+	if in.Gw != "" {
+		gw = net.ParseIP(in.Gw)
+		if gw == nil {
+			return nil, fmt.Errorf("invalid gateway %q", in.Gw)
+		}
+	}
+
+	return &netlink.Route{
+		LinkIndex: link.Attrs().Index,
+		Dst:       dst,
+		Gw:        gw,
+		Type:      typ,
+		Table:     in.Table,
+	}, nil
+}
+
+// encodeRouteID and decodeRouteID turn the (table, link index, dst)
+// tuple that identifies a route into an opaque URL-safe id, since a
+// route's CIDR destination contains a "/" that can't appear raw in a
+// path segment.
+func encodeRouteID(rt netlink.Route) string {
+	dst := "default"
+	if rt.Dst != nil {
+		dst = rt.Dst.String()
+	}
+	raw := fmt.Sprintf("%d|%d|%s", rt.Table, rt.LinkIndex, dst)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeRouteID(id string) (*netlink.Route, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil {
+		return nil, fmt.Errorf("base64 decode: %w", err)
+	}
+
+	parts := strings.SplitN(string(raw), "|", 3)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed id")
+	}
+
+	table, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed table: %w", err)
+	}
+
+	linkIndex, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed link index: %w", err)
+	}
+
+	rt := &netlink.Route{Table: table, LinkIndex: linkIndex}
+	if parts[2] != "default" {
+		_, dst, err := net.ParseCIDR(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("malformed dst: %w", err)
+		}
+		rt.Dst = dst
+	}
+
+	return rt, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("api.writeJSON json.Encode() err:%v", err)
+	}
+}
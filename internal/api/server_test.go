@@ -0,0 +1,312 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// fakeLink is a minimal netlink.Link for use in tests.
+type fakeLink struct {
+	netlink.LinkAttrs
+}
+
+func (f *fakeLink) Attrs() *netlink.LinkAttrs { return &f.LinkAttrs }
+func (f *fakeLink) Type() string              { return "fake" }
+
+// fakeAPI is an in-memory netlinkAPI used to drive the control API's
+// handlers through httptest without touching the real kernel routing
+// table.
+type fakeAPI struct {
+	linksByName  map[string]netlink.Link
+	linksByIndex map[int]netlink.Link
+	routes       []netlink.Route
+
+	replaced []netlink.Route
+	deleted  []netlink.Route
+}
+
+func newFakeAPI() *fakeAPI {
+	return &fakeAPI{
+		linksByName:  make(map[string]netlink.Link),
+		linksByIndex: make(map[int]netlink.Link),
+	}
+}
+
+func (f *fakeAPI) addLink(name string, index int) {
+	l := &fakeLink{LinkAttrs: netlink.LinkAttrs{Name: name, Index: index}}
+	f.linksByName[name] = l
+	f.linksByIndex[index] = l
+}
+
+func (f *fakeAPI) LinkByName(name string) (netlink.Link, error) {
+	if l, ok := f.linksByName[name]; ok {
+		return l, nil
+	}
+	return nil, fmt.Errorf("fakeAPI: link %q not found", name)
+}
+func (f *fakeAPI) LinkByIndex(index int) (netlink.Link, error) {
+	if l, ok := f.linksByIndex[index]; ok {
+		return l, nil
+	}
+	return nil, fmt.Errorf("fakeAPI: link index %d not found", index)
+}
+func (f *fakeAPI) RouteList(link netlink.Link, family int) ([]netlink.Route, error) {
+	return f.routes, nil
+}
+func (f *fakeAPI) RouteReplace(route *netlink.Route) error {
+	f.replaced = append(f.replaced, *route)
+	f.routes = append(f.routes, *route)
+	return nil
+}
+func (f *fakeAPI) RouteDel(route *netlink.Route) error {
+	f.deleted = append(f.deleted, *route)
+	return nil
+}
+
+func TestListRoutes(t *testing.T) {
+	api := newFakeAPI()
+	api.addLink("eth0", 7)
+	api.routes = []netlink.Route{
+		{LinkIndex: 7, Table: unix.RT_TABLE_MAIN, Type: unix.RTN_UNICAST},
+		{LinkIndex: 7, Table: 100, Type: unix.RTN_UNICAST},
+	}
+
+	s := newServer(0, api)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/routes", nil)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /v1/routes status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var out []Route
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("len(out) = %d, want 2", len(out))
+	}
+	if out[0].Link != "eth0" {
+		t.Errorf("out[0].Link = %q, want %q", out[0].Link, "eth0")
+	}
+}
+
+func TestListRoutesFilteredByTable(t *testing.T) {
+	api := newFakeAPI()
+	api.addLink("eth0", 7)
+	api.routes = []netlink.Route{
+		{LinkIndex: 7, Table: unix.RT_TABLE_MAIN, Type: unix.RTN_UNICAST},
+		{LinkIndex: 7, Table: 100, Type: unix.RTN_UNICAST},
+	}
+
+	s := newServer(0, api)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/routes?table=100", nil)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /v1/routes?table=100 status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var out []Route
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(out) != 1 || out[0].Table != 100 {
+		t.Fatalf("out = %+v, want one route with table 100", out)
+	}
+}
+
+func TestListRoutesInvalidTable(t *testing.T) {
+	s := newServer(0, newFakeAPI())
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/routes?table=nope", nil)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("GET /v1/routes?table=nope status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCreateRoute(t *testing.T) {
+	api := newFakeAPI()
+	api.addLink("eth0", 7)
+
+	s := newServer(0, api)
+
+	body := strings.NewReader(`{"link":"eth0","dst":"232.0.0.0/8","type":"multicast"}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/routes", body)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /v1/routes status = %d, want %d, body:%s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+	if len(api.replaced) != 1 {
+		t.Fatalf("len(api.replaced) = %d, want 1", len(api.replaced))
+	}
+
+	var out Route
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if out.ID == "" {
+		t.Error("out.ID is empty, want an encoded route id")
+	}
+}
+
+func TestCreateRouteInvalidBody(t *testing.T) {
+	s := newServer(0, newFakeAPI())
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/routes", strings.NewReader("not json"))
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("POST /v1/routes (bad body) status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCreateRouteUnknownLink(t *testing.T) {
+	s := newServer(0, newFakeAPI())
+
+	body := strings.NewReader(`{"link":"does-not-exist"}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/routes", body)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("POST /v1/routes (unknown link) status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestDeleteRoute(t *testing.T) {
+	api := newFakeAPI()
+	s := newServer(0, api)
+
+	id := encodeRouteID(netlink.Route{Table: unix.RT_TABLE_MAIN, LinkIndex: 7})
+	req := httptest.NewRequest(http.MethodDelete, "/v1/routes/"+id, nil)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE /v1/routes/%s status = %d, want %d", id, rec.Code, http.StatusNoContent)
+	}
+	if len(api.deleted) != 1 {
+		t.Fatalf("len(api.deleted) = %d, want 1", len(api.deleted))
+	}
+}
+
+func TestDeleteRouteInvalidID(t *testing.T) {
+	s := newServer(0, newFakeAPI())
+
+	req := httptest.NewRequest(http.MethodDelete, "/v1/routes/not-a-valid-id!!", nil)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("DELETE /v1/routes/<bad id> status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestEncodeDecodeRouteID(t *testing.T) {
+	cases := []struct {
+		name string
+		rt   netlink.Route
+	}{
+		{
+			name: "no dst encodes as default",
+			rt:   netlink.Route{Table: unix.RT_TABLE_MAIN, LinkIndex: 3},
+		},
+		{
+			name: "dst round-trips",
+			rt:   netlink.Route{Table: unix.RT_TABLE_MAIN, LinkIndex: 3, Dst: mustCIDR(t, "232.0.0.0/8")},
+		},
+		{
+			name: "non-default table round-trips",
+			rt:   netlink.Route{Table: 100, LinkIndex: 7, Dst: mustCIDR(t, "10.0.0.0/24")},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			id := encodeRouteID(c.rt)
+
+			got, err := decodeRouteID(id)
+			if err != nil {
+				t.Fatalf("decodeRouteID(%q): %v", id, err)
+			}
+
+			if got.Table != c.rt.Table || got.LinkIndex != c.rt.LinkIndex {
+				t.Errorf("decodeRouteID(%q) = %+v, want table=%d linkIndex=%d", id, got, c.rt.Table, c.rt.LinkIndex)
+			}
+
+			wantDst := ""
+			if c.rt.Dst != nil {
+				wantDst = c.rt.Dst.String()
+			}
+			gotDst := ""
+			if got.Dst != nil {
+				gotDst = got.Dst.String()
+			}
+			if gotDst != wantDst {
+				t.Errorf("decodeRouteID(%q).Dst = %q, want %q", id, gotDst, wantDst)
+			}
+		})
+	}
+}
+
+func TestDecodeRouteIDErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		id   string
+	}{
+		{"not base64", "not valid base64!!"},
+		{"wrong field count", rawID(t, "254|3")},
+		{"malformed table", rawID(t, "nope|3|default")},
+		{"malformed link index", rawID(t, "254|nope|default")},
+		{"malformed dst", rawID(t, "254|3|not-a-cidr")},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := decodeRouteID(c.id); err == nil {
+				t.Errorf("decodeRouteID(%q) err = nil, want error", c.id)
+			}
+		})
+	}
+}
+
+func TestFromAPIRouteUnknownLink(t *testing.T) {
+	_, err := fromAPIRoute(newFakeAPI(), Route{Link: "definitely-not-a-real-interface-xyz"})
+	if err == nil {
+		t.Fatal("fromAPIRoute() err = nil, want error for nonexistent link")
+	}
+}
+
+func mustCIDR(t *testing.T, s string) *net.IPNet {
+	t.Helper()
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		t.Fatalf("net.ParseCIDR(%s): %v", s, err)
+	}
+	return n
+}
+
+func rawID(t *testing.T, raw string) string {
+	t.Helper()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
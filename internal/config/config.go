@@ -0,0 +1,91 @@
+// Package config defines the declarative configuration schema that
+// goRoutes loads from a YAML file, describing the routes (and, in turn,
+// tunnels) that the reconciliation loop should converge the kernel
+// towards.
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the top level declarative configuration for goRoutes.
+type Config struct {
+	Routes  []Route  `yaml:"routes"`
+	Tunnels []Tunnel `yaml:"tunnels"`
+}
+
+// Route describes a single netlink route that the reconciler should
+// ensure exists in the kernel routing table.
+type Route struct {
+	// Name is a human readable identifier for logging; it is not used
+	// to match against the kernel, the route fields below are.
+	Name string `yaml:"name"`
+
+	Dst  string `yaml:"dst"`  // CIDR, e.g. "232.0.0.0/8"
+	Link string `yaml:"link"` // interface name
+
+	// Type is one of "unicast", "multicast", "blackhole", "anycast".
+	// Defaults to "unicast" when empty.
+	Type string `yaml:"type"`
+
+	Table    int `yaml:"table"`
+	Protocol int `yaml:"protocol"`
+	Metric   int `yaml:"metric"`
+
+	Gw string `yaml:"gw"` // nexthop/gateway IP
+
+	// Via optionally references a tunnel to route through instead of
+	// Link, formatted "tunnel:<name>" where <name> matches a Tunnel's
+	// Name in this Config. When set, the tunnel is provisioned first
+	// and its link is used for the route.
+	Via string `yaml:"via"`
+
+	// NetNS is the name of the network namespace (as found under
+	// /var/run/netns) to operate in. Empty means the host namespace.
+	NetNS string `yaml:"netns"`
+}
+
+// Tunnel type names understood by internal/tunnel.
+const (
+	TunnelTypeGRE    = "gre"
+	TunnelTypeIPIP   = "ipip"
+	TunnelTypeIP6Tun = "ip6tnl"
+	TunnelTypeVXLAN  = "vxlan"
+)
+
+// Tunnel describes a tunnel link to provision, mirroring the
+// netlink.Gretun example previously commented out in
+// cmd/goRoutes/goRoutes.go, generalized to GRE, IP-in-IP, IPv6-in-IPv6,
+// and VXLAN.
+type Tunnel struct {
+	Name string `yaml:"name"`
+
+	// Type is one of TunnelTypeGRE, TunnelTypeIPIP, TunnelTypeIP6Tun,
+	// or TunnelTypeVXLAN. Defaults to TunnelTypeGRE when empty.
+	Type string `yaml:"type"`
+
+	Remote string `yaml:"remote"`
+	Local  string `yaml:"local"`
+
+	// VNI and Port are only meaningful for TunnelTypeVXLAN.
+	VNI  int `yaml:"vni"`
+	Port int `yaml:"port"`
+}
+
+// Load reads and parses the YAML configuration file at path.
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config.Load os.ReadFile(%s): %w", path, err)
+	}
+
+	c := &Config{}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("config.Load yaml.Unmarshal(%s): %w", path, err)
+	}
+
+	return c, nil
+}
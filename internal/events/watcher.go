@@ -0,0 +1,238 @@
+// Package events streams kernel route and link changes via netlink
+// subscriptions, logging them and exposing them as Prometheus metrics so
+// goRoutes can act as a routing observability daemon, not just a
+// one-shot tool.
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	updateChannelSize = 64
+
+	routeCountIntervalCst = 30 * time.Second
+)
+
+var (
+	pC = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "events",
+			Name:      "goRoutes",
+			Help:      "goRoutes netlink event counters",
+		},
+		[]string{"function", "variable", "type"},
+	)
+	pRouteCount = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: "events",
+			Name:      "goRoutes_route_count",
+			Help:      "current number of kernel routes, by table",
+		},
+		[]string{"table"},
+	)
+	// pRouteEvents counts route add/remove notifications by table,
+	// protocol, and route type, as opposed to pC (which only labels by
+	// function/variable/type and would make this cardinality awkward
+	// to bolt on for a single call site).
+	pRouteEvents = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "events",
+			Name:      "goRoutes_route_events",
+			Help:      "goRoutes route add/remove events by table, protocol, and type",
+		},
+		[]string{"action", "table", "protocol", "type"},
+	)
+)
+
+// Watcher subscribes to netlink route and link events and records them
+// as structured logs and Prometheus metrics.
+type Watcher struct {
+	debugLevel int
+}
+
+// New returns a Watcher.
+func New(debugLevel int) *Watcher {
+	return &Watcher{debugLevel: debugLevel}
+}
+
+// Run subscribes to route and link events, and periodically snapshots
+// the route count per table, until ctx is cancelled.
+func (w *Watcher) Run(ctx context.Context) {
+	go w.watchRoutes(ctx)
+	go w.watchLinks(ctx)
+	go w.pollRouteCounts(ctx)
+
+	<-ctx.Done()
+}
+
+// watchRoutes subscribes to RTM_NEWROUTE/RTM_DELROUTE notifications.
+func (w *Watcher) watchRoutes(ctx context.Context) {
+	updates := make(chan netlink.RouteUpdate, updateChannelSize)
+	done := make(chan struct{})
+	defer close(done)
+
+	if err := netlink.RouteSubscribe(updates, done); err != nil {
+		log.Printf("events.watchRoutes netlink.RouteSubscribe() err:%v", err)
+		pC.WithLabelValues("watchRoutes", "RouteSubscribe", "error").Inc()
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case u, ok := <-updates:
+			if !ok {
+				return
+			}
+			w.handleRouteUpdate(u)
+		}
+	}
+}
+
+// handleRouteUpdate records a route add/remove notification. Note that
+// "replaced" is never reported here: netlink.RouteUpdate carries only
+// the RTM_NEWROUTE/RTM_DELROUTE message type, with no flag indicating
+// whether a RTM_NEWROUTE was a fresh add or a replace of an existing
+// route, so that distinction isn't observable from this event stream.
+func (w *Watcher) handleRouteUpdate(u netlink.RouteUpdate) {
+	action := routeUpdateAction(u.Type)
+	typ := routeTypeName(u.Route.Type)
+	table := tableName(u.Route.Table)
+	protocol := fmt.Sprintf("%d", u.Route.Protocol)
+
+	if w.debugLevel > 10 {
+		log.Printf("events route %s table:%d dst:%v proto:%d type:%s",
+			action, u.Route.Table, u.Route.Dst, u.Route.Protocol, typ)
+	}
+
+	pRouteEvents.WithLabelValues(action, table, protocol, typ).Inc()
+}
+
+// watchLinks subscribes to RTM_NEWLINK/RTM_DELLINK notifications.
+func (w *Watcher) watchLinks(ctx context.Context) {
+	updates := make(chan netlink.LinkUpdate, updateChannelSize)
+	done := make(chan struct{})
+	defer close(done)
+
+	if err := netlink.LinkSubscribe(updates, done); err != nil {
+		log.Printf("events.watchLinks netlink.LinkSubscribe() err:%v", err)
+		pC.WithLabelValues("watchLinks", "LinkSubscribe", "error").Inc()
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case u, ok := <-updates:
+			if !ok {
+				return
+			}
+			w.handleLinkUpdate(u)
+		}
+	}
+}
+
+func (w *Watcher) handleLinkUpdate(u netlink.LinkUpdate) {
+	state := "down"
+	if u.Link.Attrs().OperState == netlink.OperUp {
+		state = "up"
+	}
+
+	if w.debugLevel > 10 {
+		log.Printf("events link %s state:%s type:%s", u.Link.Attrs().Name, state, u.Link.Type())
+	}
+
+	pC.WithLabelValues("handleLinkUpdate", state, u.Link.Type()).Inc()
+}
+
+// pollRouteCounts periodically snapshots the number of routes per table
+// and publishes it as a gauge, since there is no "current count" signal
+// in the RouteSubscribe event stream itself.
+func (w *Watcher) pollRouteCounts(ctx context.Context) {
+	t := time.NewTicker(routeCountIntervalCst)
+	defer t.Stop()
+
+	w.updateRouteCounts()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			w.updateRouteCounts()
+		}
+	}
+}
+
+func (w *Watcher) updateRouteCounts() {
+	rts, err := netlink.RouteList(nil, unix.AF_UNSPEC)
+	if err != nil {
+		log.Printf("events.updateRouteCounts netlink.RouteList() err:%v", err)
+		pC.WithLabelValues("updateRouteCounts", "RouteList", "error").Inc()
+		return
+	}
+
+	counts := make(map[int]float64)
+	for _, rt := range rts {
+		counts[rt.Table]++
+	}
+
+	for table, count := range counts {
+		pRouteCount.WithLabelValues(tableName(table)).Set(count)
+	}
+}
+
+// routeUpdateAction maps a netlink route update's message type to a
+// short action name suitable for a metric/log label.
+func routeUpdateAction(msgType uint16) string {
+	switch msgType {
+	case unix.RTM_NEWROUTE:
+		return "added"
+	case unix.RTM_DELROUTE:
+		return "removed"
+	default:
+		return "unknown"
+	}
+}
+
+// routeTypeName maps a unix.RTN_* route type to its label name.
+func routeTypeName(t int) string {
+	switch t {
+	case unix.RTN_UNICAST:
+		return "unicast"
+	case unix.RTN_MULTICAST:
+		return "multicast"
+	case unix.RTN_BLACKHOLE:
+		return "blackhole"
+	case unix.RTN_ANYCAST:
+		return "anycast"
+	default:
+		return "other"
+	}
+}
+
+// tableName maps well-known routing table IDs to their names so metric
+// labels stay readable.
+func tableName(table int) string {
+	switch table {
+	case unix.RT_TABLE_MAIN:
+		return "main"
+	case unix.RT_TABLE_LOCAL:
+		return "local"
+	case unix.RT_TABLE_DEFAULT:
+		return "default"
+	default:
+		return fmt.Sprintf("%d", table)
+	}
+}
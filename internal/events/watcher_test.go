@@ -0,0 +1,70 @@
+package events
+
+import (
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestRouteUpdateAction(t *testing.T) {
+	cases := []struct {
+		name    string
+		msgType uint16
+		want    string
+	}{
+		{"new route is added", unix.RTM_NEWROUTE, "added"},
+		{"del route is removed", unix.RTM_DELROUTE, "removed"},
+		{"unrecognized type", unix.RTM_NEWLINK, "unknown"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := routeUpdateAction(c.msgType); got != c.want {
+				t.Errorf("routeUpdateAction(%d) = %q, want %q", c.msgType, got, c.want)
+			}
+		})
+	}
+}
+
+func TestRouteTypeName(t *testing.T) {
+	cases := []struct {
+		name string
+		typ  int
+		want string
+	}{
+		{"unicast", unix.RTN_UNICAST, "unicast"},
+		{"multicast", unix.RTN_MULTICAST, "multicast"},
+		{"blackhole", unix.RTN_BLACKHOLE, "blackhole"},
+		{"anycast", unix.RTN_ANYCAST, "anycast"},
+		{"unrecognized", 255, "other"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := routeTypeName(c.typ); got != c.want {
+				t.Errorf("routeTypeName(%d) = %q, want %q", c.typ, got, c.want)
+			}
+		})
+	}
+}
+
+func TestTableName(t *testing.T) {
+	cases := []struct {
+		name  string
+		table int
+		want  string
+	}{
+		{"main", unix.RT_TABLE_MAIN, "main"},
+		{"local", unix.RT_TABLE_LOCAL, "local"},
+		{"default", unix.RT_TABLE_DEFAULT, "default"},
+		{"custom table falls back to its number", 100, "100"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := tableName(c.table); got != c.want {
+				t.Errorf("tableName(%d) = %q, want %q", c.table, got, c.want)
+			}
+		})
+	}
+}
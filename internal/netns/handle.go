@@ -0,0 +1,88 @@
+// Package netns provides namespace-aware netlink operations, binding a
+// netlink.Handle to a target Linux network namespace so routes and
+// links can be managed inside container/CNI namespaces, not just the
+// host namespace.
+package netns
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/vishvananda/netlink"
+	vishnetns "github.com/vishvananda/netns"
+)
+
+// Handle binds a netlink.Handle to a specific network namespace.
+type Handle struct {
+	name    string
+	ns      vishnetns.NsHandle
+	netlink *netlink.Handle
+}
+
+// ByName returns a Handle bound to the named network namespace, as
+// found under /var/run/netns (ip netns convention).
+func ByName(name string) (*Handle, error) {
+	ns, err := vishnetns.GetFromName(name)
+	if err != nil {
+		return nil, fmt.Errorf("netns.ByName vishnetns.GetFromName(%s): %w", name, err)
+	}
+	return newHandle(name, ns)
+}
+
+// ByPath returns a Handle bound to the network namespace at path, e.g.
+// a CNI-managed namespace under /var/run/netns or /proc/<pid>/ns/net.
+func ByPath(path string) (*Handle, error) {
+	ns, err := vishnetns.GetFromPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("netns.ByPath vishnetns.GetFromPath(%s): %w", path, err)
+	}
+	return newHandle(path, ns)
+}
+
+func newHandle(name string, ns vishnetns.NsHandle) (*Handle, error) {
+	nh, err := netlink.NewHandleAt(ns)
+	if err != nil {
+		ns.Close()
+		return nil, fmt.Errorf("netns.newHandle netlink.NewHandleAt(%s): %w", name, err)
+	}
+
+	return &Handle{
+		name:    name,
+		ns:      ns,
+		netlink: nh,
+	}, nil
+}
+
+// Netlink returns the netlink.Handle bound to this namespace, for
+// issuing RouteAdd/RouteDel/RouteReplace and link operations inside it.
+func (h *Handle) Netlink() *netlink.Handle {
+	return h.netlink
+}
+
+// Close releases the namespace file descriptor and the netlink handle.
+func (h *Handle) Close() {
+	h.netlink.Delete()
+	h.ns.Close()
+}
+
+// Do runs fn with the calling goroutine's OS thread locked and switched
+// into this namespace, restoring the original namespace before
+// returning. Use this for link operations (e.g. LinkAdd for a tunnel)
+// that don't go through a namespaced netlink.Handle.
+func (h *Handle) Do(fn func() error) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	orig, err := vishnetns.Get()
+	if err != nil {
+		return fmt.Errorf("netns.Do vishnetns.Get(): %w", err)
+	}
+	defer orig.Close()
+
+	if err := vishnetns.Set(h.ns); err != nil {
+		return fmt.Errorf("netns.Do vishnetns.Set(%s): %w", h.name, err)
+	}
+	defer vishnetns.Set(orig)
+
+	return fn()
+}
@@ -0,0 +1,374 @@
+// Package routes implements a reconciliation loop that diffs a
+// declarative set of desired netlink routes (from internal/config)
+// against the kernel's current routing table and issues
+// RouteAdd/RouteDel/RouteReplace to converge the two.
+package routes
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/randomizedcoder/goRoutes/internal/config"
+	"github.com/randomizedcoder/goRoutes/internal/netns"
+	"github.com/randomizedcoder/goRoutes/internal/tunnel"
+)
+
+// tunnelViaPrefixCst is the config.Route.Via prefix that marks a route
+// as going through a provisioned tunnel rather than a plain Link.
+const tunnelViaPrefixCst = "tunnel:"
+
+const (
+	reconcileIntervalCst = 30 * time.Second
+
+	// managedProtocolCst is the default netlink protocol used for
+	// routes whose config doesn't set one explicitly. It is purely
+	// cosmetic (visible in "ip route" output) and, unlike in earlier
+	// versions of this reconciler, is never relied on to decide which
+	// kernel routes are ours: that bookkeeping lives in
+	// Reconciler.owned, so a route is still recognized as managed even
+	// when its config sets a custom protocol.
+	managedProtocolCst = 200
+)
+
+var (
+	pC = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "routes",
+			Name:      "goRoutes",
+			Help:      "goRoutes route reconciler counters",
+		},
+		[]string{"function", "variable", "type"},
+	)
+)
+
+// routeTypes maps the declarative "type" string to the corresponding
+// unix.RTN_* route type constant.
+var routeTypes = map[string]int{
+	"":          unix.RTN_UNICAST,
+	"unicast":   unix.RTN_UNICAST,
+	"multicast": unix.RTN_MULTICAST,
+	"blackhole": unix.RTN_BLACKHOLE,
+	"anycast":   unix.RTN_ANYCAST,
+}
+
+// netlinkAPI is the subset of netlink operations the reconciler needs,
+// satisfied both by the top-level netlink functions (host namespace,
+// via hostNetlink) and by *netlink.Handle (a specific namespace, via
+// internal/netns). It also satisfies tunnel.LinkAPI, so a tunnel
+// referenced by a route's "via" can be provisioned in that same
+// namespace.
+type netlinkAPI interface {
+	LinkByName(name string) (netlink.Link, error)
+	LinkAdd(link netlink.Link) error
+	LinkDel(link netlink.Link) error
+	RouteList(link netlink.Link, family int) ([]netlink.Route, error)
+	RouteAdd(route *netlink.Route) error
+	RouteDel(route *netlink.Route) error
+	RouteReplace(route *netlink.Route) error
+}
+
+// hostNetlink implements netlinkAPI against the host (default) network
+// namespace, via the package-level netlink functions.
+type hostNetlink struct{}
+
+func (hostNetlink) LinkByName(name string) (netlink.Link, error) { return netlink.LinkByName(name) }
+func (hostNetlink) LinkAdd(link netlink.Link) error              { return netlink.LinkAdd(link) }
+func (hostNetlink) LinkDel(link netlink.Link) error              { return netlink.LinkDel(link) }
+func (hostNetlink) RouteList(link netlink.Link, family int) ([]netlink.Route, error) {
+	return netlink.RouteList(link, family)
+}
+func (hostNetlink) RouteAdd(route *netlink.Route) error     { return netlink.RouteAdd(route) }
+func (hostNetlink) RouteDel(route *netlink.Route) error     { return netlink.RouteDel(route) }
+func (hostNetlink) RouteReplace(route *netlink.Route) error { return netlink.RouteReplace(route) }
+
+// Reconciler periodically compares the desired routes from a
+// config.Config against the kernel routing table and converges the two,
+// entering each route's target network namespace as needed.
+type Reconciler struct {
+	debugLevel int
+	interval   time.Duration
+	cfg        *config.Config
+
+	nsHandles map[string]*netns.Handle
+
+	// owned tracks, per namespace, the routeKey of every desired route
+	// this reconciler has added or replaced, or found already present
+	// and matching in the kernel, independent of any field on the route
+	// (e.g. Protocol) so that a config-supplied protocol can never make
+	// an owned route look foreign.
+	owned map[string]map[string]netlink.Route
+}
+
+// New returns a Reconciler for the supplied config.
+func New(debugLevel int, cfg *config.Config) *Reconciler {
+	return &Reconciler{
+		debugLevel: debugLevel,
+		interval:   reconcileIntervalCst,
+		cfg:        cfg,
+		nsHandles:  make(map[string]*netns.Handle),
+		owned:      make(map[string]map[string]netlink.Route),
+	}
+}
+
+// Close releases any network namespace handles opened while reconciling.
+func (r *Reconciler) Close() {
+	for _, h := range r.nsHandles {
+		h.Close()
+	}
+}
+
+// Run starts the reconciliation loop, blocking until ctx is cancelled.
+func (r *Reconciler) Run(ctx context.Context) {
+	r.reconcile()
+
+	t := time.NewTicker(r.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			r.reconcile()
+		}
+	}
+}
+
+// reconcile groups the configured routes by target namespace and
+// reconciles each namespace independently, since routes in different
+// namespaces never need to be diffed against each other.
+func (r *Reconciler) reconcile() {
+	byNS := make(map[string][]config.Route)
+	for _, rc := range r.cfg.Routes {
+		byNS[rc.NetNS] = append(byNS[rc.NetNS], rc)
+	}
+
+	for ns, rcs := range byNS {
+		api, err := r.netlinkFor(ns)
+		if err != nil {
+			log.Printf("routes.reconcile r.netlinkFor(%s) err:%v", ns, err)
+			pC.WithLabelValues("reconcile", "netlinkFor", "error").Inc()
+			continue
+		}
+		r.reconcileNamespace(ns, api, rcs)
+	}
+}
+
+// netlinkFor returns the netlinkAPI to use for namespace ns, opening and
+// caching a netns.Handle the first time a non-host namespace is seen.
+func (r *Reconciler) netlinkFor(ns string) (netlinkAPI, error) {
+	if ns == "" {
+		return hostNetlink{}, nil
+	}
+
+	if h, ok := r.nsHandles[ns]; ok {
+		return h.Netlink(), nil
+	}
+
+	h, err := netns.ByName(ns)
+	if err != nil {
+		return nil, fmt.Errorf("netns.ByName(%s): %w", ns, err)
+	}
+	r.nsHandles[ns] = h
+
+	return h.Netlink(), nil
+}
+
+// reconcileNamespace diffs the desired routes for a single namespace
+// against that namespace's current kernel routes and issues
+// RouteAdd/RouteReplace/RouteDel as needed. Which kernel routes are
+// "ours" to delete is tracked in r.owned, not inferred from the
+// kernel-reported route (e.g. its Protocol), since that field is
+// user-configurable per route.
+func (r *Reconciler) reconcileNamespace(ns string, api netlinkAPI, rcs []config.Route) {
+	desired := make(map[string]netlink.Route, len(rcs))
+	for _, rc := range rcs {
+		rt, err := buildRoute(api, rc, r.cfg.Tunnels)
+		if err != nil {
+			log.Printf("routes.reconcileNamespace buildRoute(%s) err:%v", rc.Name, err)
+			pC.WithLabelValues("reconcileNamespace", "buildRoute", "error").Inc()
+			continue
+		}
+		desired[routeKey(*rt)] = *rt
+	}
+
+	current, errL := api.RouteList(nil, unix.AF_UNSPEC)
+	if errL != nil {
+		log.Printf("routes.reconcileNamespace ns:%q api.RouteList() err:%v", ns, errL)
+		pC.WithLabelValues("reconcileNamespace", "RouteList", "error").Inc()
+		return
+	}
+
+	currentByKey := make(map[string]netlink.Route, len(current))
+	for _, rt := range current {
+		currentByKey[routeKey(rt)] = rt
+	}
+
+	owned := r.owned[ns]
+	if owned == nil {
+		owned = make(map[string]netlink.Route)
+		r.owned[ns] = owned
+	}
+
+	for key, want := range desired {
+		have, existsInKernel := currentByKey[key]
+
+		switch {
+		case !existsInKernel:
+			if err := api.RouteAdd(&want); err != nil {
+				log.Printf("routes.reconcileNamespace api.RouteAdd(%v) err:%v", want, err)
+				pC.WithLabelValues("reconcileNamespace", "RouteAdd", "error").Inc()
+				continue
+			}
+			pC.WithLabelValues("reconcileNamespace", "RouteAdd", "ok").Inc()
+		case !routesEqual(have, want):
+			if err := api.RouteReplace(&want); err != nil {
+				log.Printf("routes.reconcileNamespace api.RouteReplace(%v) err:%v", want, err)
+				pC.WithLabelValues("reconcileNamespace", "RouteReplace", "error").Inc()
+				continue
+			}
+			pC.WithLabelValues("reconcileNamespace", "RouteReplace", "ok").Inc()
+		}
+
+		owned[key] = want
+	}
+
+	// Anything we own that's no longer desired gets removed, provided
+	// it's still actually present in the kernel (it may have already
+	// been deleted out from under us).
+	for key, stale := range owned {
+		if _, ok := desired[key]; ok {
+			continue
+		}
+
+		delete(owned, key)
+
+		if _, existsInKernel := currentByKey[key]; !existsInKernel {
+			continue
+		}
+
+		if err := api.RouteDel(&stale); err != nil {
+			log.Printf("routes.reconcileNamespace api.RouteDel(%v) err:%v", stale, err)
+			pC.WithLabelValues("reconcileNamespace", "RouteDel", "error").Inc()
+			continue
+		}
+		pC.WithLabelValues("reconcileNamespace", "RouteDel", "ok").Inc()
+	}
+
+	if r.debugLevel > 10 {
+		log.Printf("routes.reconcileNamespace ns:%q desired:%d current:%d", ns, len(desired), len(current))
+	}
+}
+
+// resolveLink returns the link a route should use: the named tunnel,
+// provisioned on demand via api, when rc.Via is "tunnel:<name>", or
+// rc.Link looked up via api otherwise. Using api for both means the
+// tunnel ends up in the same namespace as the route referencing it.
+func resolveLink(api netlinkAPI, rc config.Route, tunnels []config.Tunnel) (netlink.Link, error) {
+	name, ok := strings.CutPrefix(rc.Via, tunnelViaPrefixCst)
+	if !ok {
+		link, err := api.LinkByName(rc.Link)
+		if err != nil {
+			return nil, fmt.Errorf("resolveLink api.LinkByName(%s): %w", rc.Link, err)
+		}
+		return link, nil
+	}
+
+	for _, t := range tunnels {
+		if t.Name == name {
+			link, err := tunnel.Ensure(api, t)
+			if err != nil {
+				return nil, fmt.Errorf("resolveLink tunnel.Ensure(%s): %w", name, err)
+			}
+			return link, nil
+		}
+	}
+
+	return nil, fmt.Errorf("resolveLink no tunnel named %q for route %s", name, rc.Name)
+}
+
+// buildRoute converts a single config.Route into a netlink.Route. The
+// route's link is resolved via api so the lookup happens in the route's
+// target namespace, unless rc.Via references a tunnel (in which case
+// the tunnel is provisioned and its link is used instead).
+func buildRoute(api netlinkAPI, rc config.Route, tunnels []config.Tunnel) (*netlink.Route, error) {
+	link, err := resolveLink(api, rc, tunnels)
+	if err != nil {
+		return nil, err
+	}
+
+	typ, ok := routeTypes[rc.Type]
+	if !ok {
+		return nil, fmt.Errorf("buildRoute unknown route type %q for %s", rc.Type, rc.Name)
+	}
+
+	var dst *net.IPNet
+	if rc.Dst != "" {
+		_, dst, err = net.ParseCIDR(rc.Dst)
+		if err != nil {
+			return nil, fmt.Errorf("buildRoute net.ParseCIDR(%s): %w", rc.Dst, err)
+		}
+	}
+
+	var gw net.IP
+	if rc.Gw != "" {
+		gw = net.ParseIP(rc.Gw)
+		if gw == nil {
+			return nil, fmt.Errorf("buildRoute net.ParseIP(%s): invalid gateway", rc.Gw)
+		}
+	}
+
+	protocol := rc.Protocol
+	if protocol == 0 {
+		protocol = managedProtocolCst
+	}
+
+	// netlink.RouteAdd/RouteList resolve an unset table to the kernel's
+	// main table (unix.RT_TABLE_MAIN), so normalize it here too,
+	// otherwise routeKey would key desired routes by "0" and never
+	// match what RouteList echoes back (it always reports the
+	// resolved table), and reconcile would retry RouteAdd forever.
+	table := rc.Table
+	if table == 0 {
+		table = unix.RT_TABLE_MAIN
+	}
+
+	return &netlink.Route{
+		LinkIndex: link.Attrs().Index,
+		Dst:       dst,
+		Gw:        gw,
+		Type:      typ,
+		Table:     table,
+		Protocol:  protocol,
+		Priority:  rc.Metric,
+	}, nil
+}
+
+// routeKey identifies a route by the fields the kernel itself uses to
+// distinguish routes, so the same logical route can be matched between
+// the desired set and netlink.RouteList's output.
+func routeKey(rt netlink.Route) string {
+	dst := "default"
+	if rt.Dst != nil {
+		dst = rt.Dst.String()
+	}
+	return fmt.Sprintf("%d|%d|%s", rt.Table, rt.LinkIndex, dst)
+}
+
+// routesEqual reports whether have already matches want closely enough
+// that no RouteReplace is necessary.
+func routesEqual(have, want netlink.Route) bool {
+	return have.Type == want.Type &&
+		have.Protocol == want.Protocol &&
+		have.Priority == want.Priority &&
+		have.Gw.Equal(want.Gw)
+}
@@ -0,0 +1,195 @@
+package routes
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/randomizedcoder/goRoutes/internal/config"
+)
+
+// fakeLink is a minimal netlink.Link for use in tests, standing in for
+// a real kernel interface.
+type fakeLink struct {
+	netlink.LinkAttrs
+}
+
+func (f *fakeLink) Attrs() *netlink.LinkAttrs { return &f.LinkAttrs }
+func (f *fakeLink) Type() string              { return "fake" }
+
+// fakeAPI is an in-memory netlinkAPI used to exercise the reconciler's
+// diff logic without touching the real kernel routing table.
+type fakeAPI struct {
+	links  map[string]netlink.Link
+	routes []netlink.Route
+
+	added    []netlink.Route
+	replaced []netlink.Route
+	deleted  []netlink.Route
+}
+
+func newFakeAPI() *fakeAPI {
+	return &fakeAPI{links: make(map[string]netlink.Link)}
+}
+
+func (f *fakeAPI) LinkByName(name string) (netlink.Link, error) {
+	if l, ok := f.links[name]; ok {
+		return l, nil
+	}
+	return nil, fmt.Errorf("fakeAPI: link %q not found", name)
+}
+func (f *fakeAPI) LinkAdd(link netlink.Link) error {
+	f.links[link.Attrs().Name] = link
+	return nil
+}
+func (f *fakeAPI) LinkDel(link netlink.Link) error {
+	delete(f.links, link.Attrs().Name)
+	return nil
+}
+func (f *fakeAPI) RouteList(link netlink.Link, family int) ([]netlink.Route, error) {
+	return f.routes, nil
+}
+func (f *fakeAPI) RouteAdd(route *netlink.Route) error {
+	f.added = append(f.added, *route)
+	f.routes = append(f.routes, *route)
+	return nil
+}
+func (f *fakeAPI) RouteDel(route *netlink.Route) error {
+	f.deleted = append(f.deleted, *route)
+	return nil
+}
+func (f *fakeAPI) RouteReplace(route *netlink.Route) error {
+	f.replaced = append(f.replaced, *route)
+	return nil
+}
+
+func mustCIDR(t *testing.T, s string) *net.IPNet {
+	t.Helper()
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		t.Fatalf("net.ParseCIDR(%s): %v", s, err)
+	}
+	return n
+}
+
+func TestRouteKey(t *testing.T) {
+	cases := []struct {
+		name string
+		rt   netlink.Route
+		want string
+	}{
+		{
+			name: "no dst is keyed as default",
+			rt:   netlink.Route{Table: unix.RT_TABLE_MAIN, LinkIndex: 3},
+			want: "254|3|default",
+		},
+		{
+			name: "dst included verbatim",
+			rt:   netlink.Route{Table: unix.RT_TABLE_MAIN, LinkIndex: 3, Dst: mustCIDR(t, "232.0.0.0/8")},
+			want: "254|3|232.0.0.0/8",
+		},
+		{
+			name: "different table is a different key",
+			rt:   netlink.Route{Table: 100, LinkIndex: 3},
+			want: "100|3|default",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := routeKey(c.rt); got != c.want {
+				t.Errorf("routeKey(%+v) = %q, want %q", c.rt, got, c.want)
+			}
+		})
+	}
+}
+
+func TestRoutesEqual(t *testing.T) {
+	base := netlink.Route{Type: unix.RTN_UNICAST, Protocol: 200, Priority: 0, Gw: net.ParseIP("10.0.0.1")}
+
+	cases := []struct {
+		name       string
+		have, want netlink.Route
+		wantEqual  bool
+	}{
+		{"identical", base, base, true},
+		{"different gw", base, netlink.Route{Type: base.Type, Protocol: base.Protocol, Gw: net.ParseIP("10.0.0.2")}, false},
+		{"different protocol", base, netlink.Route{Type: base.Type, Protocol: 4, Gw: base.Gw}, false},
+		{"different metric", base, netlink.Route{Type: base.Type, Protocol: base.Protocol, Priority: 10, Gw: base.Gw}, false},
+		{"different type", base, netlink.Route{Type: unix.RTN_BLACKHOLE, Protocol: base.Protocol, Gw: base.Gw}, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := routesEqual(c.have, c.want); got != c.wantEqual {
+				t.Errorf("routesEqual(%+v, %+v) = %v, want %v", c.have, c.want, got, c.wantEqual)
+			}
+		})
+	}
+}
+
+func TestBuildRouteDefaultsTableToMain(t *testing.T) {
+	api := newFakeAPI()
+	api.links["eth0"] = &fakeLink{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 7}}
+
+	rc := config.Route{Name: "example", Link: "eth0", Dst: "232.0.0.0/8", Type: "multicast"}
+
+	rt, err := buildRoute(api, rc, nil)
+	if err != nil {
+		t.Fatalf("buildRoute() err:%v", err)
+	}
+
+	if rt.Table != unix.RT_TABLE_MAIN {
+		t.Errorf("rt.Table = %d, want %d (RT_TABLE_MAIN)", rt.Table, unix.RT_TABLE_MAIN)
+	}
+
+	// Since netlink.RouteList echoes back RT_TABLE_MAIN for a route
+	// added with Table 0, routeKey on the built route must match the
+	// key the reconciler will see when it re-lists the kernel's
+	// routes, or every reconcile would look like a missing route.
+	kernelEcho := netlink.Route{Table: unix.RT_TABLE_MAIN, LinkIndex: rt.LinkIndex, Dst: rt.Dst}
+	if routeKey(*rt) != routeKey(kernelEcho) {
+		t.Errorf("routeKey(built) = %q, routeKey(kernel echo) = %q, want equal", routeKey(*rt), routeKey(kernelEcho))
+	}
+}
+
+func TestBuildRouteExplicitProtocolIsPreserved(t *testing.T) {
+	api := newFakeAPI()
+	api.links["eth0"] = &fakeLink{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 7}}
+
+	rc := config.Route{Name: "example", Link: "eth0", Protocol: unix.RTPROT_STATIC}
+
+	rt, err := buildRoute(api, rc, nil)
+	if err != nil {
+		t.Fatalf("buildRoute() err:%v", err)
+	}
+	if rt.Protocol != unix.RTPROT_STATIC {
+		t.Errorf("rt.Protocol = %d, want %d", rt.Protocol, unix.RTPROT_STATIC)
+	}
+}
+
+func TestReconcileNamespaceTracksOwnershipIndependentOfProtocol(t *testing.T) {
+	api := newFakeAPI()
+	api.links["eth0"] = &fakeLink{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 7}}
+
+	r := New(0, &config.Config{})
+	rc := config.Route{Name: "custom-proto", Link: "eth0", Protocol: unix.RTPROT_STATIC}
+
+	// First pass: route doesn't exist yet, gets added.
+	r.reconcileNamespace("", api, []config.Route{rc})
+	if len(api.added) != 1 {
+		t.Fatalf("after first reconcile, len(api.added) = %d, want 1", len(api.added))
+	}
+
+	// Second pass: the desired route is gone from config, and the
+	// reconciler must still recognize and remove the one it added,
+	// even though its Protocol is the custom unix.RTPROT_STATIC rather
+	// than managedProtocolCst.
+	r.reconcileNamespace("", api, nil)
+	if len(api.deleted) != 1 {
+		t.Fatalf("after second reconcile, len(api.deleted) = %d, want 1", len(api.deleted))
+	}
+}
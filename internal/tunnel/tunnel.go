@@ -0,0 +1,192 @@
+// Package tunnel turns a declarative config.Tunnel into a real GRE,
+// IP-in-IP, IPv6-in-IPv6, or VXLAN netlink link, creating, replacing, or
+// deleting it as needed so the kernel matches the desired configuration.
+// It replaces the commented-out netlink.Gretun example that used to sit
+// at the bottom of cmd/goRoutes/goRoutes.go.
+package tunnel
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/vishvananda/netlink"
+
+	"github.com/randomizedcoder/goRoutes/internal/config"
+)
+
+var pC = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Subsystem: "tunnel",
+		Name:      "goRoutes",
+		Help:      "goRoutes tunnel provisioning counters",
+	},
+	[]string{"function", "variable", "type"},
+)
+
+// LinkAPI is the subset of netlink link operations tunnel provisioning
+// needs, satisfied both by the top-level netlink functions (Host, the
+// host namespace) and by *netlink.Handle (a specific namespace, via
+// internal/netns), so a tunnel can be created in the same namespace as
+// the route that references it.
+type LinkAPI interface {
+	LinkByName(name string) (netlink.Link, error)
+	LinkAdd(link netlink.Link) error
+	LinkDel(link netlink.Link) error
+}
+
+// hostLink implements LinkAPI against the host (default) network
+// namespace, via the package-level netlink functions.
+type hostLink struct{}
+
+func (hostLink) LinkByName(name string) (netlink.Link, error) { return netlink.LinkByName(name) }
+func (hostLink) LinkAdd(link netlink.Link) error              { return netlink.LinkAdd(link) }
+func (hostLink) LinkDel(link netlink.Link) error              { return netlink.LinkDel(link) }
+
+// Host is the LinkAPI for the host network namespace.
+var Host LinkAPI = hostLink{}
+
+// Ensure creates the link described by t if it doesn't exist, replaces
+// it if it exists but has drifted from t, or leaves it alone if it
+// already matches. It only returns an error for a real failure, never
+// for "already exists and matches". api determines which namespace the
+// link is created in; pass Host for the host namespace, or a
+// *netlink.Handle (e.g. from internal/netns) for another namespace.
+func Ensure(api LinkAPI, t config.Tunnel) (netlink.Link, error) {
+	want, err := build(t)
+	if err != nil {
+		pC.WithLabelValues("Ensure", "build", "error").Inc()
+		return nil, fmt.Errorf("tunnel.Ensure build(%s): %w", t.Name, err)
+	}
+
+	existing, errL := api.LinkByName(t.Name)
+	if errL != nil {
+		if _, ok := errL.(netlink.LinkNotFoundError); !ok {
+			pC.WithLabelValues("Ensure", "LinkByName", "error").Inc()
+			return nil, fmt.Errorf("tunnel.Ensure api.LinkByName(%s): %w", t.Name, errL)
+		}
+		return add(api, t.Name, want)
+	}
+
+	if matches(existing, want) {
+		pC.WithLabelValues("Ensure", "matches", "noop").Inc()
+		return existing, nil
+	}
+
+	if err := api.LinkDel(existing); err != nil {
+		pC.WithLabelValues("Ensure", "LinkDel", "error").Inc()
+		return nil, fmt.Errorf("tunnel.Ensure api.LinkDel(%s): %w", t.Name, err)
+	}
+
+	return add(api, t.Name, want)
+}
+
+func add(api LinkAPI, name string, want netlink.Link) (netlink.Link, error) {
+	if err := api.LinkAdd(want); err != nil {
+		pC.WithLabelValues("add", "LinkAdd", "error").Inc()
+		return nil, fmt.Errorf("tunnel.add api.LinkAdd(%s): %w", name, err)
+	}
+	pC.WithLabelValues("add", "LinkAdd", "ok").Inc()
+
+	link, err := api.LinkByName(name)
+	if err != nil {
+		pC.WithLabelValues("add", "LinkByName", "error").Inc()
+		return nil, fmt.Errorf("tunnel.add api.LinkByName(%s): %w", name, err)
+	}
+	return link, nil
+}
+
+// Delete removes the named tunnel link if it exists, and is a no-op if
+// it does not.
+func Delete(api LinkAPI, name string) error {
+	link, err := api.LinkByName(name)
+	if err != nil {
+		if _, ok := err.(netlink.LinkNotFoundError); ok {
+			return nil
+		}
+		pC.WithLabelValues("Delete", "LinkByName", "error").Inc()
+		return fmt.Errorf("tunnel.Delete api.LinkByName(%s): %w", name, err)
+	}
+
+	if err := api.LinkDel(link); err != nil {
+		pC.WithLabelValues("Delete", "LinkDel", "error").Inc()
+		return fmt.Errorf("tunnel.Delete api.LinkDel(%s): %w", name, err)
+	}
+
+	pC.WithLabelValues("Delete", "LinkDel", "ok").Inc()
+	return nil
+}
+
+// build converts a declarative config.Tunnel into the netlink.Link
+// implementation matching its Type.
+func build(t config.Tunnel) (netlink.Link, error) {
+	la := netlink.NewLinkAttrs()
+	la.Name = t.Name
+
+	remote, local, err := parseEndpoints(t)
+	if err != nil {
+		return nil, err
+	}
+
+	switch t.Type {
+	case "", config.TunnelTypeGRE:
+		return &netlink.Gretun{LinkAttrs: la, Remote: remote, Local: local}, nil
+
+	case config.TunnelTypeIPIP:
+		return &netlink.Iptun{LinkAttrs: la, Remote: remote, Local: local}, nil
+
+	case config.TunnelTypeIP6Tun:
+		return &netlink.Ip6tnl{LinkAttrs: la, Remote: remote, Local: local}, nil
+
+	case config.TunnelTypeVXLAN:
+		return &netlink.Vxlan{
+			LinkAttrs: la,
+			VxlanId:   t.VNI,
+			Group:     remote,
+			SrcAddr:   local,
+			Port:      t.Port,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("tunnel.build unknown tunnel type %q for %s", t.Type, t.Name)
+	}
+}
+
+func parseEndpoints(t config.Tunnel) (remote, local net.IP, err error) {
+	if t.Remote != "" {
+		remote = net.ParseIP(t.Remote)
+		if remote == nil {
+			return nil, nil, fmt.Errorf("tunnel.parseEndpoints invalid remote %q for %s", t.Remote, t.Name)
+		}
+	}
+	if t.Local != "" {
+		local = net.ParseIP(t.Local)
+		if local == nil {
+			return nil, nil, fmt.Errorf("tunnel.parseEndpoints invalid local %q for %s", t.Local, t.Name)
+		}
+	}
+	return remote, local, nil
+}
+
+// matches reports whether existing already satisfies want closely
+// enough that Ensure can skip a needless delete+recreate.
+func matches(existing, want netlink.Link) bool {
+	switch w := want.(type) {
+	case *netlink.Gretun:
+		e, ok := existing.(*netlink.Gretun)
+		return ok && e.Remote.Equal(w.Remote) && e.Local.Equal(w.Local)
+	case *netlink.Iptun:
+		e, ok := existing.(*netlink.Iptun)
+		return ok && e.Remote.Equal(w.Remote) && e.Local.Equal(w.Local)
+	case *netlink.Ip6tnl:
+		e, ok := existing.(*netlink.Ip6tnl)
+		return ok && e.Remote.Equal(w.Remote) && e.Local.Equal(w.Local)
+	case *netlink.Vxlan:
+		e, ok := existing.(*netlink.Vxlan)
+		return ok && e.VxlanId == w.VxlanId && e.Group.Equal(w.Group) && e.SrcAddr.Equal(w.SrcAddr) &&
+			(w.Port == 0 || e.Port == w.Port)
+	default:
+		return false
+	}
+}
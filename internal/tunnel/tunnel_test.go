@@ -0,0 +1,131 @@
+package tunnel
+
+import (
+	"net"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/randomizedcoder/goRoutes/internal/config"
+)
+
+func TestBuild(t *testing.T) {
+	cases := []struct {
+		name    string
+		tunnel  config.Tunnel
+		wantErr bool
+		check   func(t *testing.T, link netlink.Link)
+	}{
+		{
+			name:   "default type is gre",
+			tunnel: config.Tunnel{Name: "gre0", Remote: "192.0.2.1", Local: "192.0.2.2"},
+			check: func(t *testing.T, link netlink.Link) {
+				g, ok := link.(*netlink.Gretun)
+				if !ok {
+					t.Fatalf("link is %T, want *netlink.Gretun", link)
+				}
+				if !g.Remote.Equal(net.ParseIP("192.0.2.1")) || !g.Local.Equal(net.ParseIP("192.0.2.2")) {
+					t.Errorf("unexpected endpoints: remote=%v local=%v", g.Remote, g.Local)
+				}
+			},
+		},
+		{
+			name:   "ipip",
+			tunnel: config.Tunnel{Name: "ipip0", Type: config.TunnelTypeIPIP, Remote: "192.0.2.1", Local: "192.0.2.2"},
+			check: func(t *testing.T, link netlink.Link) {
+				if _, ok := link.(*netlink.Iptun); !ok {
+					t.Fatalf("link is %T, want *netlink.Iptun", link)
+				}
+			},
+		},
+		{
+			name:   "ip6tnl",
+			tunnel: config.Tunnel{Name: "ip6t0", Type: config.TunnelTypeIP6Tun, Remote: "2001:db8::1", Local: "2001:db8::2"},
+			check: func(t *testing.T, link netlink.Link) {
+				if _, ok := link.(*netlink.Ip6tnl); !ok {
+					t.Fatalf("link is %T, want *netlink.Ip6tnl", link)
+				}
+			},
+		},
+		{
+			name:   "vxlan",
+			tunnel: config.Tunnel{Name: "vxlan0", Type: config.TunnelTypeVXLAN, Remote: "192.0.2.1", Local: "192.0.2.2", VNI: 42, Port: 4789},
+			check: func(t *testing.T, link netlink.Link) {
+				v, ok := link.(*netlink.Vxlan)
+				if !ok {
+					t.Fatalf("link is %T, want *netlink.Vxlan", link)
+				}
+				if v.VxlanId != 42 || v.Port != 4789 {
+					t.Errorf("unexpected vxlan id/port: %d/%d", v.VxlanId, v.Port)
+				}
+			},
+		},
+		{
+			name:    "unknown type",
+			tunnel:  config.Tunnel{Name: "bad0", Type: "wireguard"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid remote",
+			tunnel:  config.Tunnel{Name: "gre0", Remote: "not-an-ip"},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			link, err := build(c.tunnel)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("build(%+v) err = nil, want error", c.tunnel)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("build(%+v) err:%v", c.tunnel, err)
+			}
+			c.check(t, link)
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	la := netlink.NewLinkAttrs()
+	la.Name = "gre0"
+
+	want := &netlink.Gretun{LinkAttrs: la, Remote: net.ParseIP("192.0.2.1"), Local: net.ParseIP("192.0.2.2")}
+
+	cases := []struct {
+		name     string
+		existing netlink.Link
+		want     netlink.Link
+		match    bool
+	}{
+		{
+			name:     "identical gretun matches",
+			existing: &netlink.Gretun{LinkAttrs: la, Remote: net.ParseIP("192.0.2.1"), Local: net.ParseIP("192.0.2.2")},
+			want:     want,
+			match:    true,
+		},
+		{
+			name:     "drifted remote does not match",
+			existing: &netlink.Gretun{LinkAttrs: la, Remote: net.ParseIP("192.0.2.9"), Local: net.ParseIP("192.0.2.2")},
+			want:     want,
+			match:    false,
+		},
+		{
+			name:     "different link type never matches",
+			existing: &netlink.Iptun{LinkAttrs: la, Remote: net.ParseIP("192.0.2.1"), Local: net.ParseIP("192.0.2.2")},
+			want:     want,
+			match:    false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := matches(c.existing, c.want); got != c.match {
+				t.Errorf("matches() = %v, want %v", got, c.match)
+			}
+		})
+	}
+}